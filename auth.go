@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultTokenTTL  = 15 * time.Minute
+	defaultClockSkew = 30 * time.Second
+)
+
+type authContextKey struct{}
+
+// Claims is the JWT payload issued by /api/token and validated by jwtAuth.
+// Scope is a space-separated list, mirroring the OAuth2 "scope" convention.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+}
+
+func (c *Claims) hasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type authErrorResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func writeAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(authErrorResponse{Message: message, Status: "error"})
+}
+
+// authUnavailableHandler serves every auth-gated route when newJWTAuthFromEnv
+// failed at startup, so a missing/misconfigured JWT_SECRET degrades just
+// those routes instead of the whole process refusing to boot.
+func authUnavailableHandler(w http.ResponseWriter, r *http.Request) {
+	writeAuthError(w, http.StatusServiceUnavailable, "JWT auth is not configured")
+}
+
+// jwtAuth validates bearer tokens signed with a single allowlisted method
+// (HS256 or RS256 — "none" is never accepted) and, on success, stores the
+// parsed claims in the request context for downstream handlers.
+type jwtAuth struct {
+	signMethod jwt.SigningMethod
+	verifyKey  interface{}
+	signKey    interface{} // only needed by the token-issuance endpoint
+	clockSkew  time.Duration
+}
+
+func newJWTAuth(method jwt.SigningMethod, verifyKey, signKey interface{}, clockSkew time.Duration) *jwtAuth {
+	if clockSkew <= 0 {
+		clockSkew = defaultClockSkew
+	}
+	return &jwtAuth{
+		signMethod: method,
+		verifyKey:  verifyKey,
+		signKey:    signKey,
+		clockSkew:  clockSkew,
+	}
+}
+
+// newJWTAuthFromEnv builds a jwtAuth from JWT_SIGNING_METHOD (HS256 by
+// default) and the matching key material, so operators can switch between a
+// shared secret and an RSA key pair without a code change.
+func newJWTAuthFromEnv() (*jwtAuth, error) {
+	method := os.Getenv("JWT_SIGNING_METHOD")
+	if method == "" {
+		method = "HS256"
+	}
+	clockSkew := time.Duration(envInt("JWT_CLOCK_SKEW_SECONDS", int(defaultClockSkew/time.Second))) * time.Second
+
+	switch method {
+	case "HS256":
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, fmt.Errorf("JWT_SECRET is required when JWT_SIGNING_METHOD=HS256")
+		}
+		key := []byte(secret)
+		return newJWTAuth(jwt.SigningMethodHS256, key, key, clockSkew), nil
+	case "RS256":
+		privPEM := os.Getenv("JWT_PRIVATE_KEY_PEM")
+		pubPEM := os.Getenv("JWT_PUBLIC_KEY_PEM")
+		if privPEM == "" || pubPEM == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_PEM and JWT_PUBLIC_KEY_PEM are required when JWT_SIGNING_METHOD=RS256")
+		}
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privPEM))
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT_PRIVATE_KEY_PEM: %w", err)
+		}
+		pub, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pubPEM))
+		if err != nil {
+			return nil, fmt.Errorf("parsing JWT_PUBLIC_KEY_PEM: %w", err)
+		}
+		return newJWTAuth(jwt.SigningMethodRS256, pub, priv, clockSkew), nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_METHOD %q (use HS256 or RS256)", method)
+	}
+}
+
+// middleware rejects requests without a valid, unexpired bearer token signed
+// with the configured method, and otherwise makes the parsed claims
+// available to next via claimsFromContext.
+func (a *jwtAuth) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			writeAuthError(w, http.StatusUnauthorized, "missing Authorization header")
+			return
+		}
+
+		rawToken, ok := strings.CutPrefix(authHeader, "Bearer ")
+		if !ok {
+			writeAuthError(w, http.StatusUnauthorized, "Authorization header must use the Bearer scheme")
+			return
+		}
+
+		claims := &Claims{}
+		parsed, err := jwt.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (interface{}, error) {
+			return a.verifyKey, nil
+		}, jwt.WithValidMethods([]string{a.signMethod.Alg()}), jwt.WithLeeway(a.clockSkew))
+		if err != nil || !parsed.Valid {
+			writeAuthError(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authContextKey{}, claims)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func claimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(authContextKey{}).(*Claims)
+	return claims, ok
+}
+
+// requireScope builds middleware that 403s any request whose claims (already
+// populated by jwtAuth.middleware) lack scope.
+func requireScope(scope string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := claimsFromContext(r.Context())
+			if !ok || !claims.hasScope(scope) {
+				writeAuthError(w, http.StatusForbidden, fmt.Sprintf("requires %q scope", scope))
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+type tokenRequest struct {
+	Subject string `json:"subject"`
+	Scope   string `json:"scope"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// newTokenHandler builds POST /api/token, which bootstraps access by trading
+// a static API key (meant for trusted operators/CI, not end users) for a
+// short-lived JWT carrying the requested scope.
+func newTokenHandler(auth *jwtAuth, bootstrapAPIKey string, ttl time.Duration) http.HandlerFunc {
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if bootstrapAPIKey == "" || r.Header.Get("X-API-Key") != bootstrapAPIKey {
+			writeAuthError(w, http.StatusUnauthorized, "invalid or missing API key")
+			return
+		}
+
+		var req tokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAuthError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Subject == "" {
+			writeAuthError(w, http.StatusBadRequest, "subject is required")
+			return
+		}
+
+		now := time.Now()
+		claims := &Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   req.Subject,
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			},
+			Scope: req.Scope,
+		}
+
+		signed, err := jwt.NewWithClaims(auth.signMethod, claims).SignedString(auth.signKey)
+		if err != nil {
+			log.Printf("Error signing token: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(tokenResponse{
+			AccessToken: signed,
+			TokenType:   "Bearer",
+			ExpiresIn:   int64(ttl.Seconds()),
+		})
+	}
+}
+
+// meHandler handles GET /api/me, echoing the caller's claims so clients can
+// confirm which subject/scope a token carries.
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := claimsFromContext(r.Context())
+	if !ok {
+		writeAuthError(w, http.StatusUnauthorized, "missing claims")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(claims)
+}
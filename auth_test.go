@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testAuth() *jwtAuth {
+	key := []byte("test-secret")
+	return newJWTAuth(jwt.SigningMethodHS256, key, key, defaultClockSkew)
+}
+
+func signTestToken(t *testing.T, auth *jwtAuth, subject, scope string, issuedAt, expiresAt time.Time) string {
+	t.Helper()
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Scope: scope,
+	}
+	signed, err := jwt.NewWithClaims(auth.signMethod, claims).SignedString(auth.signKey)
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+	return signed
+}
+
+func TestJWTAuthMissingHeaderRejected(t *testing.T) {
+	auth := testAuth()
+	protected := auth.middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for missing Authorization header, got %d", rr.Code)
+	}
+}
+
+func TestJWTAuthExpiredTokenRejected(t *testing.T) {
+	auth := testAuth()
+	token := signTestToken(t, auth, "alice", "", time.Now().Add(-time.Hour), time.Now().Add(-time.Minute))
+
+	protected := auth.middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an expired token, got %d", rr.Code)
+	}
+}
+
+func TestJWTAuthWrongSigningMethodRejected(t *testing.T) {
+	auth := testAuth()
+
+	// A token signed with HS384 instead of the configured HS256 must be
+	// rejected even though it is otherwise well-formed and unexpired.
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "eve",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS384, claims).SignedString(auth.signKey)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	protected := auth.middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a token signed with an unexpected method, got %d", rr.Code)
+	}
+}
+
+func TestJWTAuthNoneAlgRejected(t *testing.T) {
+	auth := testAuth()
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "eve",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("failed to sign unsigned token: %v", err)
+	}
+
+	protected := auth.middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an alg=none token, got %d", rr.Code)
+	}
+}
+
+func TestJWTAuthValidTokenPopulatesClaims(t *testing.T) {
+	auth := testAuth()
+	token := signTestToken(t, auth, "alice", "admin", time.Now(), time.Now().Add(time.Hour))
+
+	protected := auth.middleware(meHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var got Claims
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to parse claims: %v", err)
+	}
+	if got.Subject != "alice" || got.Scope != "admin" {
+		t.Errorf("unexpected claims: %+v", got)
+	}
+}
+
+func TestRequireScopeRejectsMissingScope(t *testing.T) {
+	auth := testAuth()
+	token := signTestToken(t, auth, "bob", "read", time.Now(), time.Now().Add(time.Hour))
+
+	protected := auth.middleware(requireScope("admin")(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/weather/admin/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 when the admin scope is missing, got %d", rr.Code)
+	}
+}
+
+func TestRequireScopeAllowsMatchingScope(t *testing.T) {
+	auth := testAuth()
+	token := signTestToken(t, auth, "bob", "read admin", time.Now(), time.Now().Add(time.Hour))
+
+	protected := auth.middleware(requireScope("admin")(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/weather/admin/refresh", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 when the admin scope is present, got %d", rr.Code)
+	}
+}
+
+func TestTokenHandlerRequiresAPIKey(t *testing.T) {
+	auth := testAuth()
+	handler := newTokenHandler(auth, "bootstrap-secret", time.Minute)
+
+	body := `{"subject":"ci","scope":"admin"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/token", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a valid API key, got %d", rr.Code)
+	}
+}
+
+func TestTokenHandlerIssuesUsableToken(t *testing.T) {
+	auth := testAuth()
+	handler := newTokenHandler(auth, "bootstrap-secret", time.Minute)
+
+	body := `{"subject":"ci","scope":"admin"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/token", strings.NewReader(body))
+	req.Header.Set("X-API-Key", "bootstrap-secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse token response: %v", err)
+	}
+
+	protected := auth.middleware(requireScope("admin")(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req2 := httptest.NewRequest(http.MethodPost, "/api/weather/admin/refresh", nil)
+	req2.Header.Set("Authorization", resp.TokenType+" "+resp.AccessToken)
+	rr2 := httptest.NewRecorder()
+	protected.ServeHTTP(rr2, req2)
+
+	if rr2.Code != http.StatusOK {
+		t.Errorf("expected the issued token to satisfy the admin scope, got %d", rr2.Code)
+	}
+}
+
+func TestNewJWTAuthFromEnvErrorsWithoutSecret(t *testing.T) {
+	t.Setenv("JWT_SIGNING_METHOD", "")
+	t.Setenv("JWT_SECRET", "")
+
+	if _, err := newJWTAuthFromEnv(); err == nil {
+		t.Error("expected an error when JWT_SECRET is unset, not a fatal exit")
+	}
+}
+
+func TestAuthUnavailableHandlerReturns503(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	rr := httptest.NewRecorder()
+	authUnavailableHandler(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL      = 10 * time.Minute
+	defaultCacheCapacity = 10 << 20 // 10 MB
+)
+
+// cachedResponse is a stored snapshot of a handler's response, cheap enough
+// to replay without re-invoking the handler.
+type cachedResponse struct {
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+	size      int64
+}
+
+type cacheItem struct {
+	key   string
+	entry cachedResponse
+}
+
+// httpCache is an LRU-evicted, TTL-expiring cache of full HTTP responses,
+// bounded by total body bytes rather than entry count so a handful of large
+// responses can't blow past the configured memory budget.
+type httpCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int64
+	used     int64
+	ll       *list.List
+	items    map[string]*list.Element
+
+	inflightMu sync.Mutex
+	inflight   map[string]*sync.WaitGroup
+}
+
+func newHTTPCache(ttl time.Duration, capacityBytes int64) *httpCache {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	if capacityBytes <= 0 {
+		capacityBytes = defaultCacheCapacity
+	}
+	return &httpCache{
+		ttl:      ttl,
+		capacity: capacityBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		inflight: make(map[string]*sync.WaitGroup),
+	}
+}
+
+// get returns the cached entry for key if present and not expired.
+func (c *httpCache) get(key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+
+	entry := el.Value.(*cacheItem).entry
+	if time.Now().After(entry.expiresAt) {
+		c.removeElement(el)
+		return cachedResponse{}, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry, true
+}
+
+// set stores entry under key, evicting least-recently-used entries until the
+// cache fits within its byte capacity.
+func (c *httpCache) set(key string, entry cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.used -= el.Value.(*cacheItem).entry.size
+		el.Value.(*cacheItem).entry = entry
+		c.used += entry.size
+		c.ll.MoveToFront(el)
+	} else {
+		el := c.ll.PushFront(&cacheItem{key: key, entry: entry})
+		c.items[key] = el
+		c.used += entry.size
+	}
+
+	for c.used > c.capacity {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+	}
+}
+
+// removeElement must be called with c.mu held.
+func (c *httpCache) removeElement(el *list.Element) {
+	item := el.Value.(*cacheItem)
+	c.used -= item.entry.size
+	c.ll.Remove(el)
+	delete(c.items, item.key)
+}
+
+// purge removes a single key, reporting whether it was present.
+func (c *httpCache) purge(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	c.removeElement(el)
+	return true
+}
+
+// purgeAll empties the cache.
+func (c *httpCache) purgeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll = list.New()
+	c.items = make(map[string]*list.Element)
+	c.used = 0
+}
+
+// cacheKey identifies a request by method, path, and query string.
+func cacheKey(r *http.Request) string {
+	return r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+}
+
+// responseBuffer captures a handler's response so it can be cached before
+// being relayed to the real client.
+type responseBuffer struct {
+	header    http.Header
+	body      []byte
+	status    int
+	wroteHead bool
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if !b.wroteHead {
+		b.WriteHeader(http.StatusOK)
+	}
+	b.body = append(b.body, p...)
+	return len(p), nil
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.wroteHead {
+		return
+	}
+	b.status = status
+	b.wroteHead = true
+}
+
+// requestsNoCache reports whether the request's Cache-Control header carries
+// a directive asking us to bypass any cached copy and force a fresh fetch.
+func requestsNoCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.TrimSpace(strings.ToLower(directive)) {
+		case "no-cache", "no-store", "max-age=0":
+			return true
+		}
+	}
+	return false
+}
+
+// middleware serves cached GET responses for next, single-flighting
+// concurrent misses for the same key so the upstream is only called once.
+// A no-cache request skips the lookup but still goes through fetchAndCache,
+// so the fresh response replaces the stale cached entry for future callers.
+func (c *httpCache) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("X-Cache", "MISS")
+			next(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+
+		if !requestsNoCache(r) {
+			if entry, ok := c.get(key); ok {
+				writeCachedResponse(w, entry, "HIT")
+				return
+			}
+
+			if entry, ok := c.awaitInflight(key); ok {
+				writeCachedResponse(w, entry, "HIT")
+				return
+			}
+		}
+
+		entry := c.fetchAndCache(key, next, r)
+		writeCachedResponse(w, entry, "MISS")
+	}
+}
+
+// awaitInflight waits for a concurrent request already populating key, if
+// one exists, and returns its result.
+func (c *httpCache) awaitInflight(key string) (cachedResponse, bool) {
+	c.inflightMu.Lock()
+	wg, ok := c.inflight[key]
+	c.inflightMu.Unlock()
+	if !ok {
+		return cachedResponse{}, false
+	}
+
+	wg.Wait()
+	return c.get(key)
+}
+
+// fetchAndCache invokes next exactly once per leader for key, caching the
+// result if it was a cacheable (200 OK) response. If we lose a race to
+// another goroutine already populating key, we wait for it and reuse its
+// entry — but only if it was actually cached; a non-cacheable leader
+// response (e.g. a non-200 from a flaky upstream) leaves nothing in the
+// cache, so we become the new leader and fetch ourselves rather than
+// replaying a zero-value entry.
+func (c *httpCache) fetchAndCache(key string, next http.HandlerFunc, r *http.Request) cachedResponse {
+	for {
+		c.inflightMu.Lock()
+		if wg, ok := c.inflight[key]; ok {
+			c.inflightMu.Unlock()
+			wg.Wait()
+			if entry, ok := c.get(key); ok {
+				return entry
+			}
+			continue
+		}
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		c.inflight[key] = wg
+		c.inflightMu.Unlock()
+
+		defer func() {
+			c.inflightMu.Lock()
+			delete(c.inflight, key)
+			c.inflightMu.Unlock()
+			wg.Done()
+		}()
+
+		buf := newResponseBuffer()
+		next(buf, r)
+
+		entry := cachedResponse{
+			status:    buf.status,
+			header:    buf.header,
+			body:      buf.body,
+			expiresAt: time.Now().Add(c.ttl),
+			size:      int64(len(buf.body)),
+		}
+
+		if entry.status == http.StatusOK {
+			c.set(key, entry)
+		}
+
+		return entry
+	}
+}
+
+func writeCachedResponse(w http.ResponseWriter, entry cachedResponse, cacheStatus string) {
+	for k, values := range entry.header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("X-Cache", cacheStatus)
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// purgeHandler handles POST /api/cache/purge, protected by a shared secret
+// header so only trusted operators can invalidate entries.
+func (c *httpCache) purgeHandler(adminSecret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if adminSecret == "" || r.Header.Get("X-Admin-Secret") != adminSecret {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if key := r.URL.Query().Get("key"); key != "" {
+			c.purge(key)
+		} else {
+			c.purgeAll()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"purged"}`))
+	}
+}
+
+// refreshHandler handles POST /api/weather/admin/refresh, forcing the entire
+// cache to be dropped so the next request repopulates it. Unlike
+// purgeHandler it carries no secret check of its own; the caller is expected
+// to gate access with jwtAuth and requireScope("admin").
+func (c *httpCache) refreshHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		c.purgeAll()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"purged"}`))
+	}
+}
+
+// envInt64 reads an integer environment variable, falling back to def if it
+// is unset or invalid.
+func envInt64(name string, def int64) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPCacheServesHitOnSecondRequest(t *testing.T) {
+	c := newHTTPCache(time.Minute, defaultCacheCapacity)
+
+	var calls int32
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}
+
+	handler := c.middleware(upstream)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+
+	rr1 := httptest.NewRecorder()
+	handler.ServeHTTP(rr1, req)
+	if rr1.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected MISS on first request, got %s", rr1.Header().Get("X-Cache"))
+	}
+
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req)
+	if rr2.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected HIT on second request, got %s", rr2.Header().Get("X-Cache"))
+	}
+	if rr2.Body.String() != rr1.Body.String() {
+		t.Error("cached body should match original response")
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected upstream to be called once, got %d", got)
+	}
+}
+
+func TestHTTPCacheSingleFlightsConcurrentMisses(t *testing.T) {
+	c := newHTTPCache(time.Minute, defaultCacheCapacity)
+
+	var calls int32
+	start := make(chan struct{})
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-start
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}
+
+	handler := c.middleware(upstream)
+
+	const n = 20
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+		}()
+	}
+
+	// Give every goroutine a chance to either hit the in-flight wait path
+	// or become the single caller actually invoking upstream.
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected exactly one upstream call across %d concurrent requests, got %d", n, got)
+	}
+}
+
+func TestHTTPCacheConcurrentMissesSurviveNonCacheableUpstream(t *testing.T) {
+	c := newHTTPCache(time.Minute, defaultCacheCapacity)
+
+	var calls int32
+	start := make(chan struct{})
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-start
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`upstream error`))
+	}
+
+	handler := c.middleware(upstream)
+
+	const n = 20
+	statuses := make([]int, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			statuses[i] = rr.Code
+		}(i)
+	}
+
+	// Give every goroutine a chance to either hit the in-flight wait path
+	// or become a caller invoking upstream.
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusBadGateway {
+			t.Errorf("request %d: got status %d, want %d", i, status, http.StatusBadGateway)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got == 0 {
+		t.Error("expected at least one upstream call")
+	}
+	if _, ok := c.get(cacheKey(httptest.NewRequest(http.MethodGet, "/api/weather", nil))); ok {
+		t.Error("a non-200 upstream response should not be cached")
+	}
+}
+
+func TestHTTPCacheNoCacheForcesRefresh(t *testing.T) {
+	c := newHTTPCache(time.Minute, defaultCacheCapacity)
+
+	var calls int32
+	upstream := func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"call":%d}`, n)))
+	}
+
+	handler := c.middleware(upstream)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	refreshReq := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	refreshReq.Header.Set("Cache-Control", "no-cache")
+	refreshRR := httptest.NewRecorder()
+	handler.ServeHTTP(refreshRR, refreshReq)
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected no-cache request to bypass cache, got %d calls", got)
+	}
+	if body := refreshRR.Body.String(); body != `{"call":2}` {
+		t.Errorf("expected the fresh response body, got %q", body)
+	}
+
+	// The refreshed response should have replaced the stale cached entry, so
+	// a plain follow-up request is served from cache without another call.
+	hitReq := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	hitRR := httptest.NewRecorder()
+	handler.ServeHTTP(hitRR, hitReq)
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected the no-cache response to repopulate the cache, got %d calls", got)
+	}
+	if body := hitRR.Body.String(); body != `{"call":2}` {
+		t.Errorf("expected the repopulated cache entry, got %q", body)
+	}
+	if hitRR.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected X-Cache: HIT, got %q", hitRR.Header().Get("X-Cache"))
+	}
+}
+
+func TestRequestsNoCacheHonorsDirectiveLists(t *testing.T) {
+	cases := map[string]bool{
+		"":                   false,
+		"max-age=300":        false,
+		"no-cache":           true,
+		"no-cache, no-store": true,
+		"max-age=0":          true,
+		"public, max-age=0":  true,
+	}
+
+	for header, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+		if header != "" {
+			req.Header.Set("Cache-Control", header)
+		}
+		if got := requestsNoCache(req); got != want {
+			t.Errorf("requestsNoCache(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestHTTPCacheTTLExpiry(t *testing.T) {
+	c := newHTTPCache(10*time.Millisecond, defaultCacheCapacity)
+	c.set("GET /x?", cachedResponse{status: http.StatusOK, header: http.Header{}, body: []byte("a"), expiresAt: time.Now().Add(-time.Second), size: 1})
+
+	if _, ok := c.get("GET /x?"); ok {
+		t.Error("expired entry should not be served")
+	}
+}
+
+func TestHTTPCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	entrySize := int64(10)
+	c := newHTTPCache(time.Minute, entrySize*2)
+
+	mk := func(body string) cachedResponse {
+		return cachedResponse{status: http.StatusOK, header: http.Header{}, body: []byte(body), expiresAt: time.Now().Add(time.Minute), size: entrySize}
+	}
+
+	c.set("a", mk("aaaaaaaaaa"))
+	c.set("b", mk("bbbbbbbbbb"))
+
+	// Touch "a" so "b" becomes the least recently used entry.
+	c.get("a")
+
+	c.set("c", mk("cccccccccc"))
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected least-recently-used entry 'b' to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected recently-used entry 'a' to survive eviction")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("expected newly-inserted entry 'c' to be present")
+	}
+}
+
+func TestPurgeHandlerRequiresSecret(t *testing.T) {
+	c := newHTTPCache(time.Minute, defaultCacheCapacity)
+	c.set("GET /api/weather?", cachedResponse{status: http.StatusOK, header: http.Header{}, body: []byte("x"), expiresAt: time.Now().Add(time.Minute), size: 1})
+
+	handler := c.purgeHandler("s3cret")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/purge", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without secret header, got %d", rr.Code)
+	}
+
+	req2 := httptest.NewRequest(http.MethodPost, "/api/cache/purge", nil)
+	req2.Header.Set("X-Admin-Secret", "s3cret")
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req2)
+	if rr2.Code != http.StatusOK {
+		t.Errorf("expected 200 with correct secret, got %d", rr2.Code)
+	}
+
+	if _, ok := c.get("GET /api/weather?"); ok {
+		t.Error("expected purge to clear the cache")
+	}
+}
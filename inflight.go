@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInFlightLimit       = 100
+	defaultInFlightWaitTimeout = 50 * time.Millisecond
+	defaultRouteTimeout        = 12 * time.Second
+	defaultLongRunningPathRE   = `/stream$`
+)
+
+// TimeoutResponse is the JSON body written when a request is aborted by
+// jsonTimeoutHandler, shaped like HealthResponse so clients parse it the
+// same way.
+type TimeoutResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// inFlightLimiter bounds how many requests a server processes concurrently,
+// the MaxRequestsInFlight pattern large API servers use to keep a slow
+// upstream (Open-Meteo) from exhausting goroutines. Paths matching
+// longRunning (future websocket/streaming endpoints) bypass the counter
+// entirely, since they're expected to hold a slot for a long time by design.
+type inFlightLimiter struct {
+	sem         chan struct{}
+	waitTimeout time.Duration
+	longRunning *regexp.Regexp
+}
+
+func newInFlightLimiter(limit int, waitTimeout time.Duration, longRunningPattern string) *inFlightLimiter {
+	if limit <= 0 {
+		limit = defaultInFlightLimit
+	}
+	if waitTimeout <= 0 {
+		waitTimeout = defaultInFlightWaitTimeout
+	}
+	if longRunningPattern == "" {
+		longRunningPattern = defaultLongRunningPathRE
+	}
+
+	return &inFlightLimiter{
+		sem:         make(chan struct{}, limit),
+		waitTimeout: waitTimeout,
+		longRunning: regexp.MustCompile(longRunningPattern),
+	}
+}
+
+// middleware rejects a request with 429 if no slot opens up within
+// waitTimeout, giving callers prompt backpressure instead of queuing
+// indefinitely behind a saturated server.
+func (l *inFlightLimiter) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l.longRunning.MatchString(r.URL.Path) {
+			next(w, r)
+			return
+		}
+
+		select {
+		case l.sem <- struct{}{}:
+			defer func() { <-l.sem }()
+			next(w, r)
+		case <-time.After(l.waitTimeout):
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Server too busy, please retry", http.StatusTooManyRequests)
+		}
+	}
+}
+
+// timeoutBuffer buffers a handler's response the way http.TimeoutHandler's
+// internal timeoutWriter does: it lets the handler keep running after we've
+// already responded to the client, discarding anything written afterward.
+type timeoutBuffer struct {
+	mu        sync.Mutex
+	header    http.Header
+	body      []byte
+	status    int
+	wroteHead bool
+	timedOut  bool
+}
+
+func newTimeoutBuffer() *timeoutBuffer {
+	return &timeoutBuffer{header: make(http.Header), status: http.StatusOK}
+}
+
+func (b *timeoutBuffer) Header() http.Header { return b.header }
+
+func (b *timeoutBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return len(p), nil
+	}
+	if !b.wroteHead {
+		b.writeHeaderLocked(http.StatusOK)
+	}
+	b.body = append(b.body, p...)
+	return len(p), nil
+}
+
+func (b *timeoutBuffer) WriteHeader(status int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writeHeaderLocked(status)
+}
+
+func (b *timeoutBuffer) writeHeaderLocked(status int) {
+	if b.wroteHead || b.timedOut {
+		return
+	}
+	b.status = status
+	b.wroteHead = true
+}
+
+// markTimedOut flags the buffer so late writes from an abandoned handler
+// goroutine are discarded, and returns whether the handler had already
+// finished (in which case the timeout lost the race and should be ignored).
+func (b *timeoutBuffer) markTimedOut() (alreadyWroteHead bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	alreadyWroteHead = b.wroteHead
+	b.timedOut = true
+	return alreadyWroteHead
+}
+
+// jsonTimeoutHandler aborts next if it does not complete within dt,
+// responding with a JSON TimeoutResponse body instead of http.TimeoutHandler's
+// plain-text default. This keeps a slow upstream from holding a connection,
+// and its goroutine, open indefinitely.
+func jsonTimeoutHandler(next http.HandlerFunc, dt time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), dt)
+		defer cancel()
+
+		buf := newTimeoutBuffer()
+		done := make(chan struct{})
+
+		go func() {
+			next(buf, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			buf.mu.Lock()
+			header, status, body := buf.header, buf.status, buf.body
+			buf.mu.Unlock()
+
+			for k, values := range header {
+				for _, v := range values {
+					w.Header().Add(k, v)
+				}
+			}
+			w.WriteHeader(status)
+			w.Write(body)
+		case <-ctx.Done():
+			if buf.markTimedOut() {
+				// The handler raced past the deadline by a hair and already
+				// committed a status code; relay what it had written rather
+				// than overwrite it with a timeout body.
+				buf.mu.Lock()
+				header, status, body := buf.header, buf.status, buf.body
+				buf.mu.Unlock()
+
+				for k, values := range header {
+					for _, v := range values {
+						w.Header().Add(k, v)
+					}
+				}
+				w.WriteHeader(status)
+				w.Write(body)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(TimeoutResponse{
+				Message: "Request timed out",
+				Status:  "error",
+			})
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInFlightLimiterRejectsBeyondCapacity(t *testing.T) {
+	const capacity = 3
+	release := make(chan struct{})
+	var active int32
+
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&active, 1)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}
+
+	l := newInFlightLimiter(capacity, 20*time.Millisecond, "")
+	handler := l.middleware(slow)
+
+	var wg sync.WaitGroup
+	codes := make([]int, capacity+1)
+	for i := 0; i < capacity+1; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			codes[i] = rr.Code
+		}(i)
+	}
+
+	// Let the first `capacity` requests acquire their slots before the
+	// (capacity+1)th is expected to have timed out waiting for one.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	rejected := 0
+	for _, code := range codes {
+		if code == http.StatusTooManyRequests {
+			rejected++
+		}
+	}
+	if rejected != 1 {
+		t.Errorf("expected exactly 1 request to be rejected with 429, got %d (codes=%v)", rejected, codes)
+	}
+}
+
+func TestInFlightLimiterBypassesLongRunningPaths(t *testing.T) {
+	l := newInFlightLimiter(1, 10*time.Millisecond, `/stream$`)
+
+	blocked := make(chan struct{})
+	blocker := l.middleware(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+		blocker.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	streamHandler := l.middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/stream", nil)
+	rr := httptest.NewRecorder()
+	streamHandler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected long-running path to bypass the limiter, got %d", rr.Code)
+	}
+
+	close(blocked)
+	wg.Wait()
+}
+
+func TestJSONTimeoutHandlerReturnsTimeoutBody(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+
+	handler := jsonTimeoutHandler(slow, 10*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+
+	var body TimeoutResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON body, got error: %v", err)
+	}
+	if body.Status != "error" {
+		t.Errorf("expected status=error, got %s", body.Status)
+	}
+}
+
+func TestJSONTimeoutHandlerPassesThroughFastHandler(t *testing.T) {
+	fast := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+
+	handler := jsonTimeoutHandler(fast, time.Second)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-Test") != "yes" {
+		t.Error("expected header from underlying handler to be relayed")
+	}
+	if rr.Body.String() != "ok" {
+		t.Errorf("expected body 'ok', got %q", rr.Body.String())
+	}
+}
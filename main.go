@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -21,13 +23,6 @@ const (
 	defaultRequestTimeout = 10 * time.Second
 )
 
-var (
-	// Simple in-memory rate limiter
-	requestCounts = make(map[string]int)
-	lastReset     = time.Now()
-	maxRequests   = 100 // Max requests per minute per IP
-)
-
 type HealthResponse struct {
 	Message string `json:"message"`
 	Status  string `json:"status"`
@@ -70,29 +65,6 @@ func securityHeaders(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// rateLimiter implements simple IP-based rate limiting
-func rateLimiter(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		now := time.Now()
-
-		// Reset counter every minute
-		if now.Sub(lastReset) > time.Minute {
-			requestCounts = make(map[string]int)
-			lastReset = now
-		}
-
-		clientIP := r.RemoteAddr
-		requestCounts[clientIP]++
-
-		if requestCounts[clientIP] > maxRequests {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
-		}
-
-		next(w, r)
-	}
-}
-
 // requestLogger logs incoming requests
 func requestLogger(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -145,81 +117,94 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func weatherHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// weatherUpstreamError distinguishes a failure to reach a provider at all
+// from the provider responding with a non-200 status, so callers can map
+// each to the appropriate response code.
+type weatherUpstreamError struct {
+	status int // upstream HTTP status; 0 if the request itself failed
+	err    error
+}
+
+func (e *weatherUpstreamError) Error() string {
+	if e.status != 0 {
+		return fmt.Sprintf("upstream returned status %d", e.status)
 	}
+	return e.err.Error()
+}
 
-	// Create context with timeout for external API call
-	ctx, cancel := context.WithTimeout(r.Context(), defaultRequestTimeout)
-	defer cancel()
+func (e *weatherUpstreamError) Unwrap() error { return e.err }
 
-	// Fetch data from Open-Meteo API with context
-	url := "https://api.open-meteo.com/v1/forecast?latitude=41.05&longitude=28.72&current=temperature_2m,wind_speed_10m&hourly=temperature_2m,relative_humidity_2m,wind_speed_10m"
+// parseLatLon reads and validates the optional lat/lon query parameters,
+// falling back to defaultLatitude/defaultLongitude when absent.
+func parseLatLon(r *http.Request) (lat, lon float64, err error) {
+	lat, lon = defaultLatitude, defaultLongitude
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create weather request", http.StatusInternalServerError)
-		return
+	if v := r.URL.Query().Get("lat"); v != "" {
+		lat, err = strconv.ParseFloat(v, 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return 0, 0, fmt.Errorf("lat must be a number between -90 and 90")
+		}
 	}
 
-	// Use a custom HTTP client with timeout
-	client := &http.Client{
-		Timeout: defaultRequestTimeout,
+	if v := r.URL.Query().Get("lon"); v != "" {
+		lon, err = strconv.ParseFloat(v, 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return 0, 0, fmt.Errorf("lon must be a number between -180 and 180")
+		}
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("Weather API request timed out")
-			http.Error(w, "Weather service timeout", http.StatusGatewayTimeout)
+	return lat, lon, nil
+}
+
+// newWeatherHandler builds the /api/weather handler backed by router,
+// supporting ?lat=&lon=&provider=&units= query parameters with a uniform
+// response schema regardless of which provider ultimately serves the
+// request.
+func newWeatherHandler(router *weatherRouter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		log.Printf("Error fetching weather data: %v", err)
-		http.Error(w, "Failed to fetch weather data", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Open-Meteo API returned status: %d", resp.StatusCode)
-		http.Error(w, "Weather service unavailable", http.StatusServiceUnavailable)
-		return
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		http.Error(w, "Failed to read weather data", http.StatusInternalServerError)
-		return
-	}
+		lat, lon, err := parseLatLon(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	var openMeteoResp OpenMeteoResponse
-	if err := json.Unmarshal(body, &openMeteoResp); err != nil {
-		log.Printf("Error parsing weather data: %v", err)
-		http.Error(w, "Failed to parse weather data", http.StatusInternalServerError)
-		return
-	}
+		units, err := parseUnits(r.URL.Query().Get("units"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-	// Transform data into required format
-	var weatherData []WeatherData
-	for i := 0; i < len(openMeteoResp.Hourly.Time) && i < len(openMeteoResp.Hourly.Temperature2m); i++ {
-		weatherData = append(weatherData, WeatherData{
-			Time:          openMeteoResp.Hourly.Time[i],
-			Temperature2m: openMeteoResp.Hourly.Temperature2m[i],
-		})
-	}
+		weatherData, _, err := router.fetch(r.Context(), lat, lon, r.URL.Query().Get("provider"), units)
+		if err != nil {
+			var upstreamErr *weatherUpstreamError
+			switch {
+			case errors.As(err, &upstreamErr) && upstreamErr.status != 0:
+				log.Printf("Weather provider returned status: %d", upstreamErr.status)
+				http.Error(w, "Weather service unavailable", http.StatusServiceUnavailable)
+			case errors.Is(err, context.DeadlineExceeded):
+				log.Printf("Weather provider request timed out")
+				http.Error(w, "Weather service timeout", http.StatusGatewayTimeout)
+			default:
+				log.Printf("Error fetching weather data: %v", err)
+				http.Error(w, "Failed to fetch weather data", http.StatusInternalServerError)
+			}
+			return
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	// Add cache control header - cache for 5 minutes
-	w.Header().Set("Cache-Control", "public, max-age=300")
-	w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		// Add cache control header - cache for 5 minutes
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(weatherData); err != nil {
-		log.Printf("Error encoding weather response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if err := json.NewEncoder(w).Encode(weatherData); err != nil {
+			log.Printf("Error encoding weather response: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 	}
 }
 
@@ -230,12 +215,87 @@ func main() {
 		port = defaultPort
 	}
 
+	// Rate limiter is shared across routes so a single client can't reset
+	// its budget by hitting a different endpoint.
+	limiter := newGCRALimiter(
+		envInt("RATE_LIMIT_PER_MINUTE", defaultMaxRatePerMinute),
+		envInt("RATE_LIMIT_BURST", defaultBurst),
+		envBool("RATE_LIMIT_TRUST_PROXY_HEADERS", false),
+	)
+
+	weatherCache := newHTTPCache(
+		time.Duration(envInt("CACHE_TTL_SECONDS", int(defaultCacheTTL/time.Second)))*time.Second,
+		envInt64("CACHE_CAPACITY_BYTES", defaultCacheCapacity),
+	)
+
+	// Open-Meteo needs no key and is always available; OpenWeatherMap is
+	// registered as a fallback whenever OWM_APP_ID is configured.
+	breakerThreshold := envInt("BREAKER_FAILURE_THRESHOLD", defaultBreakerFailureThreshold)
+	breakerCooldown := time.Duration(envInt("BREAKER_COOLDOWN_SECONDS", int(defaultBreakerCooldown/time.Second))) * time.Second
+
+	backends := []*providerBackend{
+		{provider: newOpenMeteoProvider(), breaker: newCircuitBreaker(breakerThreshold, breakerCooldown)},
+	}
+	if owmAppID := os.Getenv("OWM_APP_ID"); owmAppID != "" {
+		backends = append(backends, &providerBackend{
+			provider: newOpenWeatherMapProvider(owmAppID),
+			breaker:  newCircuitBreaker(breakerThreshold, breakerCooldown),
+		})
+	}
+	router := newWeatherRouter(backends...)
+
+	// Global in-flight cap so a burst of slow upstream calls can't exhaust
+	// server goroutines; routes matching the long-running regex (future
+	// streaming endpoints) bypass it.
+	inFlight := newInFlightLimiter(
+		envInt("INFLIGHT_LIMIT", defaultInFlightLimit),
+		time.Duration(envInt("INFLIGHT_WAIT_MS", int(defaultInFlightWaitTimeout/time.Millisecond)))*time.Millisecond,
+		os.Getenv("LONG_RUNNING_PATH_REGEX"),
+	)
+	routeTimeout := time.Duration(envInt("ROUTE_TIMEOUT_SECONDS", int(defaultRouteTimeout/time.Second))) * time.Second
+
+	// A missing/misconfigured JWT_SECRET only disables the auth-gated
+	// routes (token issuance, /api/me, the admin refresh) — it must not
+	// take down the rest of the server, including the public /api/health.
+	auth, err := newJWTAuthFromEnv()
+	if err != nil {
+		log.Printf("JWT auth disabled, token/admin routes will return 503: %v", err)
+	}
+	tokenTTL := time.Duration(envInt("JWT_TOKEN_TTL_SECONDS", int(defaultTokenTTL/time.Second))) * time.Second
+
 	// Apply middleware chain to handlers
-	healthHandler := securityHeaders(rateLimiter(requestLogger(helloHandler)))
-	weatherHandler := securityHeaders(rateLimiter(requestLogger(weatherHandler)))
+	healthHandler := securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(requestLogger(helloHandler), routeTimeout))))
+	weatherHandler := securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(requestLogger(weatherCache.middleware(newWeatherHandler(router))), routeTimeout))))
+	cachePurgeHandler := securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(weatherCache.purgeHandler(os.Getenv("CACHE_ADMIN_SECRET")), routeTimeout))))
+
+	var tokenHandler, meAPIHandler, weatherAdminRefreshHandler http.HandlerFunc
+	if auth != nil {
+		tokenHandler = securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(newTokenHandler(auth, os.Getenv("BOOTSTRAP_API_KEY"), tokenTTL), routeTimeout))))
+		meAPIHandler = securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(auth.middleware(meHandler), routeTimeout))))
+		weatherAdminRefreshHandler = securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(auth.middleware(requireScope("admin")(weatherCache.refreshHandler())), routeTimeout))))
+	} else {
+		tokenHandler = securityHeaders(limiter.middleware(inFlight.middleware(jsonTimeoutHandler(authUnavailableHandler, routeTimeout))))
+		meAPIHandler = tokenHandler
+		weatherAdminRefreshHandler = tokenHandler
+	}
+
+	// The stream handler hijacks the connection for the websocket upgrade,
+	// so it skips the in-flight counter and JSON timeout wrapper (neither
+	// of which can coexist with a hijacked, long-lived connection) — the
+	// same "long-running" carve-out the in-flight limiter's regex models.
+	streamer := newWeatherStreamer(router, time.Duration(envInt("STREAM_POLL_INTERVAL_SECONDS", int(defaultStreamPollInterval/time.Second)))*time.Second)
+	streamHandler := securityHeaders(limiter.middleware(requestLogger(streamer.handle)))
+
+	streamCtx, stopStreamer := context.WithCancel(context.Background())
+	go streamer.run(streamCtx)
 
 	http.HandleFunc("/api/health", healthHandler)
 	http.HandleFunc("/api/weather", weatherHandler)
+	http.HandleFunc("/api/weather/stream", streamHandler)
+	http.HandleFunc("/api/weather/admin/refresh", weatherAdminRefreshHandler)
+	http.HandleFunc("/api/cache/purge", cachePurgeHandler)
+	http.HandleFunc("/api/token", tokenHandler)
+	http.HandleFunc("/api/me", meAPIHandler)
 
 	// Create server with timeouts
 	server := &http.Server{
@@ -260,6 +320,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopStreamer()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -270,4 +331,4 @@ func main() {
 	}
 
 	log.Println("Server stopped")
-}
\ No newline at end of file
+}
@@ -15,7 +15,11 @@ func TestWeatherHandler(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(weatherHandler)
+	router := newWeatherRouter(&providerBackend{
+		provider: &stubProvider{name: "stub", fn: okData(18.5)},
+		breaker:  newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	})
+	handler := newWeatherHandler(router)
 
 	handler.ServeHTTP(rr, req)
 
@@ -139,12 +143,12 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 
 	// Check all security headers
 	expectedHeaders := map[string]string{
-		"X-Content-Type-Options":    "nosniff",
-		"X-Frame-Options":           "DENY",
-		"X-XSS-Protection":          "1; mode=block",
-		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
-		"Content-Security-Policy":   "default-src 'self'",
-		"Access-Control-Allow-Origin": "*",
+		"X-Content-Type-Options":       "nosniff",
+		"X-Frame-Options":              "DENY",
+		"X-XSS-Protection":             "1; mode=block",
+		"Strict-Transport-Security":    "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy":      "default-src 'self'",
+		"Access-Control-Allow-Origin":  "*",
 		"Access-Control-Allow-Methods": "GET, OPTIONS",
 		"Access-Control-Allow-Headers": "Content-Type, Authorization",
 	}
@@ -172,4 +176,4 @@ func TestCORSPreflight(t *testing.T) {
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
-}
\ No newline at end of file
+}
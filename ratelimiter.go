@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRatePerMinute = 100
+	defaultBurst            = 10
+	rateLimiterGCInterval   = time.Minute
+)
+
+// gcraLimiter implements the Generic Cell Rate Algorithm: for each key it
+// tracks a single theoretical arrival time (TAT) instead of a counter, so
+// bursts are bounded smoothly across window boundaries rather than reset
+// wholesale every minute.
+type gcraLimiter struct {
+	mu sync.Mutex
+
+	ratePerMinute    int64         // configured steady-state requests-per-minute ceiling
+	emissionInterval time.Duration // time a single request "costs" at the steady rate
+	burst            int64         // number of requests allowed to arrive back-to-back
+	tat              map[string]time.Time
+
+	trustProxyHeaders bool
+}
+
+func newGCRALimiter(ratePerMinute, burst int, trustProxyHeaders bool) *gcraLimiter {
+	if ratePerMinute <= 0 {
+		ratePerMinute = defaultMaxRatePerMinute
+	}
+	if burst <= 0 {
+		burst = defaultBurst
+	}
+
+	l := &gcraLimiter{
+		ratePerMinute:     int64(ratePerMinute),
+		emissionInterval:  time.Minute / time.Duration(ratePerMinute),
+		burst:             int64(burst),
+		tat:               make(map[string]time.Time),
+		trustProxyHeaders: trustProxyHeaders,
+	}
+
+	go l.gcLoop()
+
+	return l
+}
+
+// allow reports whether a request for key is permitted at time now, along
+// with the number of requests still available in the current burst and how
+// long the caller should wait before retrying if it is not.
+func (l *gcraLimiter) allow(key string, now time.Time) (ok bool, remaining int64, retryAfter time.Duration) {
+	// (burst-1) emission intervals may elapse "in the past" relative to TAT
+	// before a request is rejected, which is what lets burst-many requests
+	// through back-to-back.
+	burstOffset := time.Duration(l.burst-1) * l.emissionInterval
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tat, seen := l.tat[key]
+	if !seen || tat.Before(now) {
+		tat = now
+	}
+
+	allowAt := tat.Add(-burstOffset)
+	if now.Before(allowAt) {
+		return false, 0, allowAt.Sub(now)
+	}
+
+	newTAT := tat.Add(l.emissionInterval)
+	l.tat[key] = newTAT
+
+	remaining = l.burst - int64(newTAT.Sub(now)/l.emissionInterval)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return true, remaining, 0
+}
+
+// gcLoop periodically evicts keys whose TAT has already elapsed, so the map
+// doesn't grow without bound for clients that stop sending requests.
+func (l *gcraLimiter) gcLoop() {
+	ticker := time.NewTicker(rateLimiterGCInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.mu.Lock()
+		for key, tat := range l.tat {
+			if tat.Before(now) {
+				delete(l.tat, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// middleware wraps next with rate limiting, keyed on the caller's real IP.
+func (l *gcraLimiter) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := l.clientIP(r)
+		now := time.Now()
+
+		ok, remaining, retryAfter := l.allow(key, now)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(l.ratePerMinute, 10))
+
+		if !ok {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		next(w, r)
+	}
+}
+
+// clientIP extracts the caller's IP from RemoteAddr, stripping the ephemeral
+// port. When trustProxyHeaders is enabled (only safe behind a trusted
+// reverse proxy) it prefers X-Forwarded-For/X-Real-IP.
+func (l *gcraLimiter) clientIP(r *http.Request) string {
+	if l.trustProxyHeaders {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			return strings.TrimSpace(first)
+		}
+		if real := r.Header.Get("X-Real-IP"); real != "" {
+			return real
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// envInt reads an integer environment variable, falling back to def if it is
+// unset or invalid.
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// envBool reads a boolean environment variable, falling back to def if it is
+// unset or invalid.
+func envBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
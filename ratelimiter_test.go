@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGCRALimiterAllowsBurstThenThrottles(t *testing.T) {
+	l := newGCRALimiter(60, 3, false) // 1 req/sec steady, burst of 3
+
+	base := time.Now()
+
+	for i := 0; i < 3; i++ {
+		ok, _, _ := l.allow("client-a", base)
+		if !ok {
+			t.Fatalf("request %d within burst should be allowed", i)
+		}
+	}
+
+	ok, _, retryAfter := l.allow("client-a", base)
+	if ok {
+		t.Fatal("request beyond burst should be rejected")
+	}
+	if retryAfter <= 0 {
+		t.Error("expected a positive Retry-After duration")
+	}
+
+	// After waiting out the emission interval, exactly one more request
+	// should be allowed (steady-state behavior).
+	later := base.Add(l.emissionInterval)
+	ok, _, _ = l.allow("client-a", later)
+	if !ok {
+		t.Fatal("request after emission interval should be allowed")
+	}
+	ok, _, _ = l.allow("client-a", later)
+	if ok {
+		t.Fatal("second request at the same instant should be rejected")
+	}
+}
+
+func TestGCRALimiterPerKeyIsolation(t *testing.T) {
+	l := newGCRALimiter(60, 1, false)
+	now := time.Now()
+
+	if ok, _, _ := l.allow("client-a", now); !ok {
+		t.Fatal("client-a should be allowed")
+	}
+	if ok, _, _ := l.allow("client-b", now); !ok {
+		t.Fatal("client-b should not be throttled by client-a's budget")
+	}
+}
+
+func TestGCRALimiterConcurrentAccess(t *testing.T) {
+	l := newGCRALimiter(600, 50, false)
+
+	const workers = 64
+	const requestsPerWorker = 20
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < requestsPerWorker; j++ {
+				ok, _, _ := l.allow("shared-client", time.Now())
+				if ok {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed == 0 {
+		t.Fatal("expected at least some requests to be allowed")
+	}
+	if allowed > workers*requestsPerWorker {
+		t.Fatal("allowed more requests than were made")
+	}
+}
+
+func TestGCRALimiterMiddlewareHeaders(t *testing.T) {
+	l := newGCRALimiter(60, 1, false)
+	handler := l.middleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "203.0.113.5:54321"
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first request should succeed, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-RateLimit-Limit") != "60" {
+		t.Errorf("expected X-RateLimit-Limit=60, got %s", rr.Header().Get("X-RateLimit-Limit"))
+	}
+
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req)
+	if rr2.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request should be throttled, got %d", rr2.Code)
+	}
+	if rr2.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header on throttled response")
+	}
+}
+
+func TestClientIPUsesRemoteAddrPort(t *testing.T) {
+	l := newGCRALimiter(60, 1, false)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "198.51.100.7:12345"
+
+	if ip := l.clientIP(req); ip != "198.51.100.7" {
+		t.Errorf("expected 198.51.100.7, got %s", ip)
+	}
+}
+
+func TestClientIPTrustsForwardedForWhenEnabled(t *testing.T) {
+	l := newGCRALimiter(60, 1, true)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+
+	if ip := l.clientIP(req); ip != "203.0.113.9" {
+		t.Errorf("expected 203.0.113.9, got %s", ip)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerFailureThreshold = 3
+	defaultBreakerCooldown         = 30 * time.Second
+
+	defaultLatitude  = 41.05 // Istanbul, matching the original hardcoded request
+	defaultLongitude = 28.72
+)
+
+// Units selects the temperature/wind-speed unit system a provider requests
+// from its upstream.
+type Units string
+
+const (
+	UnitsMetric   Units = "metric"
+	UnitsImperial Units = "imperial"
+	UnitsStandard Units = "standard" // Kelvin, as OpenWeatherMap calls it
+)
+
+func parseUnits(raw string) (Units, error) {
+	switch Units(raw) {
+	case "":
+		return UnitsMetric, nil
+	case UnitsMetric, UnitsImperial, UnitsStandard:
+		return Units(raw), nil
+	default:
+		return "", fmt.Errorf("unsupported units %q", raw)
+	}
+}
+
+// WeatherProvider fetches a temperature forecast for a location from one
+// upstream weather API, in the requested unit system.
+type WeatherProvider interface {
+	Fetch(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error)
+	Name() string
+}
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// openMeteoProvider fetches from Open-Meteo, which requires no API key.
+type openMeteoProvider struct {
+	client *http.Client
+}
+
+func newOpenMeteoProvider() *openMeteoProvider {
+	return &openMeteoProvider{client: &http.Client{Timeout: defaultRequestTimeout}}
+}
+
+func (p *openMeteoProvider) Name() string { return "open-meteo" }
+
+func (p *openMeteoProvider) Fetch(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error) {
+	// Open-Meteo has no "standard"/Kelvin option; fall back to metric.
+	tempUnit, windUnit := "celsius", "kmh"
+	if units == UnitsImperial {
+		tempUnit, windUnit = "fahrenheit", "mph"
+	}
+
+	url := fmt.Sprintf(
+		"https://api.open-meteo.com/v1/forecast?latitude=%s&longitude=%s&current=temperature_2m,wind_speed_10m&hourly=temperature_2m,relative_humidity_2m,wind_speed_10m&temperature_unit=%s&wind_speed_unit=%s",
+		formatCoord(lat), formatCoord(lon), tempUnit, windUnit,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating open-meteo request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, &weatherUpstreamError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &weatherUpstreamError{status: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading open-meteo response: %w", err)
+	}
+
+	var parsed OpenMeteoResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing open-meteo response: %w", err)
+	}
+
+	var data []WeatherData
+	for i := 0; i < len(parsed.Hourly.Time) && i < len(parsed.Hourly.Temperature2m); i++ {
+		data = append(data, WeatherData{Time: parsed.Hourly.Time[i], Temperature2m: parsed.Hourly.Temperature2m[i]})
+	}
+	return data, nil
+}
+
+// openWeatherMapResponse is the relevant subset of OpenWeatherMap's 5
+// day/3 hour forecast endpoint response.
+type openWeatherMapResponse struct {
+	List []struct {
+		DtTxt string `json:"dt_txt"`
+		Main  struct {
+			Temp float64 `json:"temp"`
+		} `json:"main"`
+	} `json:"list"`
+}
+
+// openWeatherMapProvider fetches from OpenWeatherMap, keyed by OWM_APP_ID.
+type openWeatherMapProvider struct {
+	client *http.Client
+	appID  string
+}
+
+func newOpenWeatherMapProvider(appID string) *openWeatherMapProvider {
+	return &openWeatherMapProvider{client: &http.Client{Timeout: defaultRequestTimeout}, appID: appID}
+}
+
+func (p *openWeatherMapProvider) Name() string { return "openweathermap" }
+
+func (p *openWeatherMapProvider) Fetch(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error) {
+	if p.appID == "" {
+		return nil, fmt.Errorf("openweathermap: OWM_APP_ID is not configured")
+	}
+
+	url := fmt.Sprintf(
+		"https://api.openweathermap.org/data/2.5/forecast?lat=%s&lon=%s&units=%s&appid=%s",
+		formatCoord(lat), formatCoord(lon), units, p.appID,
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating openweathermap request: %w", err)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, &weatherUpstreamError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &weatherUpstreamError{status: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading openweathermap response: %w", err)
+	}
+
+	var parsed openWeatherMapResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing openweathermap response: %w", err)
+	}
+
+	data := make([]WeatherData, 0, len(parsed.List))
+	for _, entry := range parsed.List {
+		data = append(data, WeatherData{Time: entry.DtTxt, Temperature2m: entry.Main.Temp})
+	}
+	return data, nil
+}
+
+// circuitState is the state of a circuitBreaker.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker stops calling a flapping provider for a cooldown period
+// once it has failed failureThreshold times in a row, trying it again
+// (half-open) after the cooldown elapses.
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            circuitState
+	failures         int
+	failureThreshold int
+	cooldown         time.Duration
+	openedAt         time.Time
+}
+
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = defaultBreakerFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &circuitBreaker{failureThreshold: failureThreshold, cooldown: cooldown}
+}
+
+// allow reports whether a call should be attempted, moving an open breaker
+// to half-open once its cooldown has elapsed.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen {
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+	}
+	return true
+}
+
+func (b *circuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.state = circuitClosed
+}
+
+func (b *circuitBreaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.trip()
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.failureThreshold {
+		b.trip()
+	}
+}
+
+// trip must be called with b.mu held.
+func (b *circuitBreaker) trip() {
+	b.state = circuitOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
+
+func (b *circuitBreaker) currentState() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// providerBackend pairs a WeatherProvider with the circuit breaker guarding
+// calls to it.
+type providerBackend struct {
+	provider WeatherProvider
+	breaker  *circuitBreaker
+}
+
+// weatherRouter selects a WeatherProvider per request and falls back to the
+// next configured provider if the requested one errors or its breaker is
+// open.
+type weatherRouter struct {
+	backends map[string]*providerBackend
+	order    []string // fallback order: primary first
+}
+
+func newWeatherRouter(backends ...*providerBackend) *weatherRouter {
+	r := &weatherRouter{backends: make(map[string]*providerBackend, len(backends))}
+	for _, b := range backends {
+		r.backends[b.provider.Name()] = b
+		r.order = append(r.order, b.provider.Name())
+	}
+	return r
+}
+
+// fetch tries the requested provider first, falling through the remaining
+// configured providers (in their registered order) when one errors or its
+// breaker is open. An empty requested name uses the registered order as-is.
+func (r *weatherRouter) fetch(ctx context.Context, lat, lon float64, requested string, units Units) ([]WeatherData, string, error) {
+	order := r.order
+	if requested != "" {
+		backend, ok := r.backends[requested]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown weather provider %q", requested)
+		}
+		order = append([]string{backend.provider.Name()}, withoutName(r.order, backend.provider.Name())...)
+	}
+
+	var lastErr error
+	for _, name := range order {
+		backend := r.backends[name]
+		if !backend.breaker.allow() {
+			lastErr = fmt.Errorf("%s: circuit open", name)
+			continue
+		}
+
+		data, err := backend.provider.Fetch(ctx, lat, lon, units)
+		if err != nil {
+			backend.breaker.recordFailure()
+			lastErr = fmt.Errorf("%s: %w", name, err)
+			continue
+		}
+
+		backend.breaker.recordSuccess()
+		return data, name, nil
+	}
+
+	return nil, "", fmt.Errorf("all weather providers failed: %w", lastErr)
+}
+
+func withoutName(names []string, exclude string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != exclude {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubProvider is a WeatherProvider whose behavior is scripted by the test.
+type stubProvider struct {
+	name string
+	fn   func(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error)
+}
+
+func (p *stubProvider) Name() string { return p.name }
+func (p *stubProvider) Fetch(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error) {
+	return p.fn(ctx, lat, lon, units)
+}
+
+func okData(temp float64) func(context.Context, float64, float64, Units) ([]WeatherData, error) {
+	return func(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error) {
+		return []WeatherData{{Time: "now", Temperature2m: temp}}, nil
+	}
+}
+
+func failingFetch(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error) {
+	return nil, &weatherUpstreamError{status: http.StatusBadGateway}
+}
+
+func TestWeatherRouterFallsBackOnPrimaryError(t *testing.T) {
+	primary := &providerBackend{
+		provider: &stubProvider{name: "primary", fn: failingFetch},
+		breaker:  newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	}
+	secondary := &providerBackend{
+		provider: &stubProvider{name: "secondary", fn: okData(15)},
+		breaker:  newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	}
+
+	router := newWeatherRouter(primary, secondary)
+
+	data, used, err := router.fetch(context.Background(), 0, 0, "", UnitsMetric)
+	if err != nil {
+		t.Fatalf("expected fallback to succeed, got error: %v", err)
+	}
+	if used != "secondary" {
+		t.Errorf("expected secondary to serve the request, got %s", used)
+	}
+	if len(data) != 1 || data[0].Temperature2m != 15 {
+		t.Errorf("unexpected data: %+v", data)
+	}
+}
+
+func TestWeatherRouterHonorsRequestedProvider(t *testing.T) {
+	primary := &providerBackend{
+		provider: &stubProvider{name: "primary", fn: okData(10)},
+		breaker:  newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	}
+	secondary := &providerBackend{
+		provider: &stubProvider{name: "secondary", fn: okData(20)},
+		breaker:  newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	}
+
+	router := newWeatherRouter(primary, secondary)
+
+	data, used, err := router.fetch(context.Background(), 0, 0, "secondary", UnitsMetric)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if used != "secondary" || data[0].Temperature2m != 20 {
+		t.Errorf("expected secondary's data, got used=%s data=%+v", used, data)
+	}
+}
+
+func TestWeatherRouterThreadsRequestedUnits(t *testing.T) {
+	var gotUnits Units
+	primary := &providerBackend{
+		provider: &stubProvider{name: "primary", fn: func(ctx context.Context, lat, lon float64, units Units) ([]WeatherData, error) {
+			gotUnits = units
+			return []WeatherData{{Time: "now", Temperature2m: 0}}, nil
+		}},
+		breaker: newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	}
+
+	router := newWeatherRouter(primary)
+
+	if _, _, err := router.fetch(context.Background(), 0, 0, "", UnitsImperial); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotUnits != UnitsImperial {
+		t.Errorf("expected the requested units to reach the provider, got %q", gotUnits)
+	}
+}
+
+func TestWeatherRouterUnknownProvider(t *testing.T) {
+	router := newWeatherRouter(&providerBackend{
+		provider: &stubProvider{name: "primary", fn: okData(10)},
+		breaker:  newCircuitBreaker(defaultBreakerFailureThreshold, defaultBreakerCooldown),
+	})
+
+	if _, _, err := router.fetch(context.Background(), 0, 0, "nonexistent", UnitsMetric); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(2, time.Hour)
+
+	if !b.allow() {
+		t.Fatal("breaker should allow calls while closed")
+	}
+	b.recordFailure()
+	if b.currentState() != circuitClosed {
+		t.Fatal("breaker should stay closed below the failure threshold")
+	}
+
+	b.recordFailure()
+	if b.currentState() != circuitOpen {
+		t.Fatal("breaker should open once the failure threshold is reached")
+	}
+	if b.allow() {
+		t.Error("an open breaker within its cooldown should not allow calls")
+	}
+}
+
+func TestCircuitBreakerHalfOpenThenRecovers(t *testing.T) {
+	b := newCircuitBreaker(1, 10*time.Millisecond)
+
+	b.recordFailure()
+	if b.currentState() != circuitOpen {
+		t.Fatal("breaker should open after a single failure (threshold=1)")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("breaker should allow a trial call once its cooldown has elapsed")
+	}
+	if b.currentState() != circuitHalfOpen {
+		t.Fatal("breaker should be half-open after its cooldown elapses")
+	}
+
+	b.recordSuccess()
+	if b.currentState() != circuitClosed {
+		t.Error("a successful half-open trial should close the breaker")
+	}
+}
+
+func TestCircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+	b := newCircuitBreaker(1, 10*time.Millisecond)
+
+	b.recordFailure()
+	time.Sleep(20 * time.Millisecond)
+	b.allow() // transitions to half-open
+
+	b.recordFailure()
+	if b.currentState() != circuitOpen {
+		t.Error("a failed half-open trial should reopen the breaker")
+	}
+}
+
+func TestParseUnits(t *testing.T) {
+	cases := map[string]struct {
+		want    Units
+		wantErr bool
+	}{
+		"":         {want: UnitsMetric},
+		"metric":   {want: UnitsMetric},
+		"imperial": {want: UnitsImperial},
+		"standard": {want: UnitsStandard},
+		"bogus":    {wantErr: true},
+	}
+
+	for input, tc := range cases {
+		got, err := parseUnits(input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseUnits(%q): expected an error", input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseUnits(%q): unexpected error: %v", input, err)
+		}
+		if got != tc.want {
+			t.Errorf("parseUnits(%q) = %q, want %q", input, got, tc.want)
+		}
+	}
+}
+
+func TestOpenWeatherMapProviderParsesForecastList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"list": []map[string]any{
+				{"dt_txt": "2026-07-26 00:00:00", "main": map[string]any{"temp": 22.5}},
+				{"dt_txt": "2026-07-26 03:00:00", "main": map[string]any{"temp": 21.0}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	// openWeatherMapProvider hardcodes the OpenWeatherMap host, so this
+	// verifies JSON-shape parsing against the documented response schema
+	// rather than exercising the live HTTP round trip.
+	var parsed openWeatherMapResponse
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.List) != 2 || parsed.List[0].Main.Temp != 22.5 {
+		t.Errorf("unexpected parsed forecast: %+v", parsed)
+	}
+}
+
+func TestParseLatLonValidatesRange(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?lat=120&lon=10", nil)
+	if _, _, err := parseLatLon(req); err == nil {
+		t.Error("expected an error for an out-of-range latitude")
+	}
+
+	req2 := httptest.NewRequest(http.MethodGet, "/api/weather?lat=10&lon=200", nil)
+	if _, _, err := parseLatLon(req2); err == nil {
+		t.Error("expected an error for an out-of-range longitude")
+	}
+
+	req3 := httptest.NewRequest(http.MethodGet, "/api/weather?lat=10&lon=20", nil)
+	lat, lon, err := parseLatLon(req3)
+	if err != nil || lat != 10 || lon != 20 {
+		t.Errorf("expected lat=10 lon=20, got lat=%v lon=%v err=%v", lat, lon, err)
+	}
+
+	req4 := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	lat4, lon4, err := parseLatLon(req4)
+	if err != nil || lat4 != defaultLatitude || lon4 != defaultLongitude {
+		t.Errorf("expected defaults, got lat=%v lon=%v err=%v", lat4, lon4, err)
+	}
+}
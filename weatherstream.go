@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultStreamPollInterval = 10 * time.Minute
+	broadcastQueueSize        = 8
+	wsWriteTimeout            = 10 * time.Second
+	wsPingInterval            = 30 * time.Second
+	wsPongWait                = 60 * time.Second
+)
+
+// broadcaster fans a message out to every subscribed websocket connection,
+// dropping any consumer whose outbound queue is full rather than letting one
+// slow client stall the others.
+type broadcaster struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]chan []byte
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{conns: make(map[*websocket.Conn]chan []byte)}
+}
+
+// AddSocket registers conn and returns the channel its writer goroutine
+// should drain.
+func (b *broadcaster) AddSocket(conn *websocket.Conn) chan []byte {
+	ch := make(chan []byte, broadcastQueueSize)
+
+	b.mu.Lock()
+	b.conns[conn] = ch
+	b.mu.Unlock()
+
+	return ch
+}
+
+// RemoveSocket unregisters conn, closing its channel so the writer goroutine
+// exits.
+func (b *broadcaster) RemoveSocket(conn *websocket.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ch, ok := b.conns[conn]; ok {
+		delete(b.conns, conn)
+		close(ch)
+	}
+}
+
+// Broadcast delivers msg to every subscribed connection's queue.
+func (b *broadcaster) Broadcast(msg []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn, ch := range b.conns {
+		select {
+		case ch <- msg:
+		default:
+			// Consumer isn't keeping up; drop it instead of blocking the
+			// fan-out to every other subscriber.
+			delete(b.conns, conn)
+			close(ch)
+			conn.Close()
+		}
+	}
+}
+
+// weatherStreamer periodically polls Open-Meteo in the background and fans
+// new snapshots out to subscribed /api/weather/stream clients.
+type weatherStreamer struct {
+	router      *weatherRouter
+	broadcaster *broadcaster
+	upgrader    websocket.Upgrader
+
+	mu       sync.RWMutex
+	snapshot []byte
+
+	pollInterval time.Duration
+}
+
+func newWeatherStreamer(router *weatherRouter, pollInterval time.Duration) *weatherStreamer {
+	if pollInterval <= 0 {
+		pollInterval = defaultStreamPollInterval
+	}
+
+	return &weatherStreamer{
+		router:       router,
+		broadcaster:  newBroadcaster(),
+		pollInterval: pollInterval,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			// CORS for the WS handshake is handled by securityHeaders
+			// upstream; accept any origin here.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// run polls on pollInterval until ctx is canceled. Call it from a single
+// background goroutine started at startup.
+func (s *weatherStreamer) run(ctx context.Context) {
+	s.poll(ctx)
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll(ctx)
+		}
+	}
+}
+
+func (s *weatherStreamer) poll(ctx context.Context) {
+	data, _, err := s.router.fetch(ctx, defaultLatitude, defaultLongitude, "", UnitsMetric)
+	if err != nil {
+		log.Printf("weather stream: poll failed: %v", err)
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("weather stream: failed to marshal snapshot: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.snapshot = payload
+	s.mu.Unlock()
+
+	s.broadcaster.Broadcast(payload)
+}
+
+func (s *weatherStreamer) currentSnapshot() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot
+}
+
+// handle upgrades the request to a websocket, sends the current buffered
+// snapshot immediately, then streams new snapshots as the background poller
+// produces them until the client disconnects.
+func (s *weatherStreamer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("weather stream: upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	ch := s.broadcaster.AddSocket(conn)
+	defer s.broadcaster.RemoveSocket(conn)
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+
+	if snapshot := s.currentSnapshot(); snapshot != nil {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteMessage(websocket.TextMessage, snapshot); err != nil {
+			return
+		}
+	}
+
+	done := make(chan struct{})
+	go s.readPump(conn, done)
+	s.writePump(conn, ch, done)
+}
+
+// readPump discards client frames (gorilla/websocket handles pong control
+// frames internally via the handler set in handle) until the connection
+// closes, unblocking writePump via done.
+func (s *weatherStreamer) readPump(conn *websocket.Conn, done chan<- struct{}) {
+	defer close(done)
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump relays broadcast snapshots and periodic pings to conn until the
+// connection closes or its queue is dropped by the broadcaster.
+func (s *weatherStreamer) writePump(conn *websocket.Conn, ch <-chan []byte, done <-chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
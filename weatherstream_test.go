@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWeatherStreamerSendsBufferedSnapshotThenBroadcasts(t *testing.T) {
+	s := newWeatherStreamer(newWeatherRouter(), time.Hour)
+
+	initial := []WeatherData{{Time: "2026-07-26T00:00", Temperature2m: 20}}
+	payload, err := json.Marshal(initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.snapshot = payload
+	s.mu.Unlock()
+
+	server := httptest.NewServer(http.HandlerFunc(s.handle))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/weather/stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected initial snapshot, got error: %v", err)
+	}
+
+	var got []WeatherData
+	if err := json.Unmarshal(msg, &got); err != nil {
+		t.Fatalf("failed to parse initial snapshot: %v", err)
+	}
+	if len(got) != 1 || got[0].Temperature2m != 20 {
+		t.Fatalf("unexpected initial snapshot: %+v", got)
+	}
+
+	// Simulate the background poller producing a new reading.
+	update := []WeatherData{{Time: "2026-07-26T01:00", Temperature2m: 21}}
+	updatePayload, err := json.Marshal(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.snapshot = updatePayload
+	s.mu.Unlock()
+	s.broadcaster.Broadcast(updatePayload)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg2, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected broadcast update, got error: %v", err)
+	}
+
+	var got2 []WeatherData
+	if err := json.Unmarshal(msg2, &got2); err != nil {
+		t.Fatalf("failed to parse update: %v", err)
+	}
+	if len(got2) != 1 || got2[0].Temperature2m != 21 {
+		t.Fatalf("unexpected update snapshot: %+v", got2)
+	}
+}
+
+func TestWeatherStreamerRemovesConnectionOnDisconnect(t *testing.T) {
+	s := newWeatherStreamer(newWeatherRouter(), time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(s.handle))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/weather/stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.broadcaster.mu.Lock()
+		n := len(s.broadcaster.conns)
+		s.broadcaster.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected broadcaster to drop the connection after client disconnect")
+}
+
+func TestBroadcasterDropsSlowConsumer(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	b := newBroadcaster()
+	ch := b.AddSocket(conn)
+
+	// Fill the queue without draining it.
+	for i := 0; i < broadcastQueueSize; i++ {
+		b.Broadcast([]byte("msg"))
+	}
+
+	b.mu.Lock()
+	_, stillPresent := b.conns[conn]
+	b.mu.Unlock()
+	if !stillPresent {
+		t.Fatal("connection should still be registered while its queue has room")
+	}
+
+	// One more broadcast should overflow the queue and drop the consumer.
+	b.Broadcast([]byte("overflow"))
+
+	b.mu.Lock()
+	_, stillPresent = b.conns[conn]
+	b.mu.Unlock()
+	if stillPresent {
+		t.Error("expected slow consumer to be dropped once its queue filled up")
+	}
+
+	drained := 0
+	for range ch {
+		drained++
+	}
+	if drained != broadcastQueueSize {
+		t.Errorf("expected %d buffered messages before closure, drained %d", broadcastQueueSize, drained)
+	}
+}